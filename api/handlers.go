@@ -5,18 +5,35 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"strconv"
+	"time"
 
+	"fleet-metrics/events"
+	"fleet-metrics/metrics"
 	"fleet-metrics/models"
+	"fleet-metrics/probe"
 	"fleet-metrics/store"
 )
 
 // Server holds the store and registers HTTP routes
 type Server struct {
-	Store *store.ServerStore
+	Store store.Backend
+
+	// Metrics is optional; when set, handlers report fleet-wide events to
+	// it in addition to updating the store.
+	Metrics *metrics.Metrics
+
+	// Bus is optional; when set, handlers publish heartbeat/upload events
+	// to it for GET /api/v1/events subscribers.
+	Bus *events.BufferedSubscription
+
+	// Prober is optional; when set, HandleHeartbeat notifies it so a
+	// device's next expected heartbeat deadline gets rescheduled.
+	Prober *probe.Prober
 }
 
 // NewServer creates a new API Server instance
-func NewServer(s *store.ServerStore) *Server {
+func NewServer(s store.Backend) *Server {
 	return &Server{
 		Store: s,
 	}
@@ -43,6 +60,15 @@ func (s *Server) HandleHeartbeat(w http.ResponseWriter, r *http.Request) {
 	}
 
 	s.Store.RecordHeartbeat(deviceID, req.SentAt)
+	if s.Metrics != nil {
+		s.Metrics.RecordHeartbeat(deviceID, req.SentAt)
+	}
+	if s.Bus != nil {
+		s.Bus.Publish(events.KindHeartbeatReceived, deviceID)
+	}
+	if s.Prober != nil {
+		s.Prober.OnHeartbeat(deviceID, req.SentAt)
+	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -74,6 +100,12 @@ func (s *Server) handlePostStats(deviceID string, w http.ResponseWriter, r *http
 	}
 
 	s.Store.RecordUpload(deviceID, req.SentAt, req.UploadTime)
+	if s.Metrics != nil {
+		s.Metrics.RecordUpload(time.Duration(req.UploadTime))
+	}
+	if s.Bus != nil {
+		s.Bus.Publish(events.KindUploadRecorded, deviceID)
+	}
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -120,3 +152,122 @@ func (s *Server) handleGetStats(deviceID string, w http.ResponseWriter, r *http.
 		fmt.Printf("Error encoding stats response: %v\n", err)
 	}
 }
+
+// HandleEvents handles GET /api/v1/events, streaming fleet activity to
+// the client as Server-Sent Events. A client reconnecting after a drop
+// can pass ?since=<event_id> to replay anything it missed.
+func (s *Server) HandleEvents(w http.ResponseWriter, r *http.Request) {
+	if s.Bus == nil {
+		writeError(w, http.StatusServiceUnavailable, "event stream not configured")
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming unsupported")
+		return
+	}
+
+	var since uint64
+	if raw := r.URL.Query().Get("since"); raw != "" {
+		if parsed, err := strconv.ParseUint(raw, 10, 64); err == nil {
+			since = parsed
+		}
+	}
+
+	sub := s.Bus.Subscribe(64)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	for _, ev := range s.Bus.Since(since) {
+		writeSSEEvent(w, ev)
+	}
+	flusher.Flush()
+
+	ctx := r.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev := <-sub.Events():
+			writeSSEEvent(w, ev)
+			flusher.Flush()
+		}
+	}
+}
+
+// writeSSEEvent writes ev to w in Server-Sent Events wire format.
+func writeSSEEvent(w http.ResponseWriter, ev events.Event) {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "id: %d\ndata: %s\n\n", ev.ID, data)
+}
+
+// HandleDeviceHealth handles GET /api/v1/devices/{device_id}/health
+func (s *Server) HandleDeviceHealth(w http.ResponseWriter, r *http.Request) {
+	deviceID := r.PathValue("device_id")
+	if deviceID == "" {
+		writeError(w, http.StatusNotFound, "device not found")
+		return
+	}
+
+	status, missedIntervals, lastSeen, ok := s.Store.Health(deviceID)
+	if !ok {
+		writeError(w, http.StatusNotFound, "Device not found")
+		return
+	}
+
+	resp := models.DeviceHealthResponse{
+		Status:          status.String(),
+		MissedIntervals: missedIntervals,
+		LastSeen:        lastSeen,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		fmt.Printf("Error encoding health response: %v\n", err)
+	}
+}
+
+// HandleFleetHealth handles GET /api/v1/health, rolling up every known
+// device's latest health verdict.
+func (s *Server) HandleFleetHealth(w http.ResponseWriter, r *http.Request) {
+	resp := models.FleetHealthResponse{
+		Devices: make(map[string]models.DeviceHealthResponse),
+	}
+
+	s.Store.Iterate(func(deviceID string) {
+		status, missedIntervals, lastSeen, ok := s.Store.Health(deviceID)
+		if !ok {
+			return
+		}
+
+		resp.Devices[deviceID] = models.DeviceHealthResponse{
+			Status:          status.String(),
+			MissedIntervals: missedIntervals,
+			LastSeen:        lastSeen,
+		}
+
+		switch status {
+		case store.HealthStale:
+			resp.Stale++
+		case store.HealthDown:
+			resp.Down++
+		default:
+			resp.Healthy++
+		}
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		fmt.Printf("Error encoding fleet health response: %v\n", err)
+	}
+}
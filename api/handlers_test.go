@@ -2,12 +2,15 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
+	"fleet-metrics/events"
 	"fleet-metrics/models"
 	"fleet-metrics/store"
 )
@@ -89,3 +92,96 @@ func TestHandleStats_PostSuccess(t *testing.T) {
 		t.Fatalf("expected 204 No Content, got %v", w.Code)
 	}
 }
+
+func TestHandleEvents_ReplaysBufferedEvents(t *testing.T) {
+	srv := setupTestServer()
+	srv.Bus = events.NewBufferedSubscription(10)
+	srv.Bus.Publish(events.KindHeartbeatReceived, "dev-1")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/events", nil).WithContext(ctx)
+	w := httptest.NewRecorder()
+
+	done := make(chan struct{})
+	go func() {
+		srv.HandleEvents(w, req)
+		close(done)
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	<-done
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 OK, got %v", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), string(events.KindHeartbeatReceived)) {
+		t.Fatalf("expected replayed heartbeat event in body, got %q", w.Body.String())
+	}
+}
+
+func TestHandleDeviceHealth_Success(t *testing.T) {
+	srv := setupTestServer()
+	srv.Store.(*store.ServerStore).SetHealth("dev-1", store.HealthStale, 3)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/devices/dev-1/health", nil)
+	w := httptest.NewRecorder()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/devices/{device_id}/health", srv.HandleDeviceHealth)
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 OK, got %v. body: %s", w.Code, w.Body.String())
+	}
+
+	var resp models.DeviceHealthResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Status != "stale" || resp.MissedIntervals != 3 {
+		t.Errorf("unexpected health response: %+v", resp)
+	}
+}
+
+func TestHandleDeviceHealth_NotFound(t *testing.T) {
+	srv := setupTestServer()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/devices/unknown/health", nil)
+	w := httptest.NewRecorder()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/devices/{device_id}/health", srv.HandleDeviceHealth)
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %v", w.Code)
+	}
+}
+
+func TestHandleFleetHealth_RollsUpDeviceCounts(t *testing.T) {
+	srv := setupTestServer()
+	srv.Store.(*store.ServerStore).SetHealth("dev-1", store.HealthDown, 10)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/health", nil)
+	w := httptest.NewRecorder()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/health", srv.HandleFleetHealth)
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 OK, got %v. body: %s", w.Code, w.Body.String())
+	}
+
+	var resp models.FleetHealthResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Down != 1 {
+		t.Errorf("expected 1 down device, got %d", resp.Down)
+	}
+	if resp.Devices["dev-1"].Status != "down" {
+		t.Errorf("expected dev-1 to be down, got %+v", resp.Devices["dev-1"])
+	}
+}
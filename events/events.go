@@ -0,0 +1,139 @@
+// Package events provides a small in-memory event bus fleet components
+// publish into, so HTTP clients can subscribe to a live stream of fleet
+// activity instead of polling per-device stats.
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// Kind identifies the type of fleet event that occurred.
+type Kind string
+
+const (
+	KindHeartbeatReceived Kind = "heartbeat_received"
+	KindUploadRecorded    Kind = "upload_recorded"
+	KindDeviceStale       Kind = "device_stale"
+)
+
+// Event is a single fleet activity record. ID is monotonically
+// increasing within a BufferedSubscription, so clients can resume a
+// stream with ?since=<id> after a reconnect.
+type Event struct {
+	ID       uint64    `json:"id"`
+	Kind     Kind      `json:"kind"`
+	DeviceID string    `json:"device_id"`
+	At       time.Time `json:"at"`
+}
+
+// BufferedSubscription is an append-only ring buffer of recent events
+// that fans them out to subscribers (e.g. SSE connections). Publishing
+// never blocks: a subscriber that falls behind has its oldest buffered
+// event dropped rather than stalling the publisher.
+type BufferedSubscription struct {
+	mu     sync.Mutex
+	buf    []Event
+	cap    int
+	nextID uint64
+	subs   map[*Subscriber]struct{}
+}
+
+// NewBufferedSubscription creates a bus that retains up to capacity
+// recent events for replay.
+func NewBufferedSubscription(capacity int) *BufferedSubscription {
+	return &BufferedSubscription{
+		cap: capacity,
+		// IDs start at 1 so Since's strict ev.ID > since can tell "replay
+		// everything" (since=0, the ?since-omitted default) apart from
+		// "I've already seen the first event" (since=1).
+		nextID: 1,
+		subs:   make(map[*Subscriber]struct{}),
+	}
+}
+
+// Publish appends an event and fans it out to all current subscribers.
+func (b *BufferedSubscription) Publish(kind Kind, deviceID string) {
+	b.mu.Lock()
+	ev := Event{ID: b.nextID, Kind: kind, DeviceID: deviceID, At: time.Now()}
+	b.nextID++
+
+	b.buf = append(b.buf, ev)
+	if len(b.buf) > b.cap {
+		b.buf = b.buf[len(b.buf)-b.cap:]
+	}
+
+	subs := make([]*Subscriber, 0, len(b.subs))
+	for sub := range b.subs {
+		subs = append(subs, sub)
+	}
+	b.mu.Unlock()
+
+	for _, sub := range subs {
+		sub.deliver(ev)
+	}
+}
+
+// Since returns buffered events with an ID greater than since, oldest
+// first, for a reconnecting client to replay.
+func (b *BufferedSubscription) Since(since uint64) []Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var out []Event
+	for _, ev := range b.buf {
+		if ev.ID > since {
+			out = append(out, ev)
+		}
+	}
+	return out
+}
+
+// Subscriber receives events published after it was created.
+type Subscriber struct {
+	bus *BufferedSubscription
+	ch  chan Event
+}
+
+// Subscribe registers a new subscriber with the given channel capacity.
+func (b *BufferedSubscription) Subscribe(bufferSize int) *Subscriber {
+	sub := &Subscriber{bus: b, ch: make(chan Event, bufferSize)}
+
+	b.mu.Lock()
+	b.subs[sub] = struct{}{}
+	b.mu.Unlock()
+
+	return sub
+}
+
+// Events returns the channel new events are delivered on.
+func (s *Subscriber) Events() <-chan Event {
+	return s.ch
+}
+
+// Close unregisters the subscriber from its bus.
+func (s *Subscriber) Close() {
+	s.bus.mu.Lock()
+	delete(s.bus.subs, s)
+	s.bus.mu.Unlock()
+}
+
+// deliver is non-blocking: if the subscriber's channel is full, the
+// oldest queued event is dropped to make room for ev.
+func (s *Subscriber) deliver(ev Event) {
+	select {
+	case s.ch <- ev:
+		return
+	default:
+	}
+
+	select {
+	case <-s.ch:
+	default:
+	}
+
+	select {
+	case s.ch <- ev:
+	default:
+	}
+}
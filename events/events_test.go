@@ -0,0 +1,44 @@
+package events
+
+import "testing"
+
+func TestBufferedSubscription_PublishAndReplay(t *testing.T) {
+	bus := NewBufferedSubscription(10)
+
+	sub := bus.Subscribe(10)
+	defer sub.Close()
+
+	bus.Publish(KindHeartbeatReceived, "dev-1")
+	bus.Publish(KindUploadRecorded, "dev-1")
+
+	first := <-sub.Events()
+	if first.Kind != KindHeartbeatReceived || first.DeviceID != "dev-1" {
+		t.Fatalf("unexpected first event: %+v", first)
+	}
+
+	second := <-sub.Events()
+	if second.Kind != KindUploadRecorded {
+		t.Fatalf("unexpected second event: %+v", second)
+	}
+
+	replay := bus.Since(first.ID)
+	if len(replay) != 1 || replay[0].ID != second.ID {
+		t.Fatalf("expected replay to contain only the event after %d, got %+v", first.ID, replay)
+	}
+}
+
+func TestBufferedSubscription_OverflowDropsOldest(t *testing.T) {
+	bus := NewBufferedSubscription(2)
+
+	bus.Publish(KindHeartbeatReceived, "dev-1")
+	bus.Publish(KindHeartbeatReceived, "dev-2")
+	bus.Publish(KindHeartbeatReceived, "dev-3")
+
+	replay := bus.Since(0)
+	if len(replay) != 2 {
+		t.Fatalf("expected buffer capped at 2 events, got %d", len(replay))
+	}
+	if replay[0].DeviceID != "dev-2" || replay[1].DeviceID != "dev-3" {
+		t.Fatalf("expected oldest event to have been dropped, got %+v", replay)
+	}
+}
@@ -1,33 +1,126 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
 
 	"fleet-metrics/api"
+	"fleet-metrics/events"
+	"fleet-metrics/metrics"
+	"fleet-metrics/probe"
 	"fleet-metrics/store"
 )
 
+const (
+	staleScanInterval   = 30 * time.Second
+	staleThreshold      = 2 * time.Minute
+	eventBufferSize     = 1000
+	statsReportInterval = 10 * time.Second
+)
+
 func main() {
+	storageFlag := flag.String("storage", "memory", `storage backend to use: "memory" or "bolt"`)
+	storagePath := flag.String("storage-path", "fleet-metrics.db", "path to the database file when --storage=bolt")
+	flag.Parse()
+
 	fmt.Println("Starting Fleet Metrics Server on port 6733...")
-	
-	serverStore := store.NewStore()
+
+	serverStore, closeStore, err := newBackend(*storageFlag, *storagePath)
+	if err != nil {
+		log.Fatalf("failed to initialize %q storage backend: %v", *storageFlag, err)
+	}
+	defer closeStore()
+
 	if err := serverStore.LoadDevices("devices.csv"); err != nil {
 		log.Fatalf("Warning: failed to load devices.csv: %v", err)
 	}
 
 	apiServer := api.NewServer(serverStore)
+	apiServer.Metrics = metrics.New(serverStore, prometheus.DefaultRegisterer)
+	apiServer.Bus = events.NewBufferedSubscription(eventBufferSize)
+
+	staleDetector := store.NewStaleDetector(serverStore, apiServer.Bus, staleScanInterval, staleThreshold)
+	staleDetector.Start()
+	defer staleDetector.Stop()
+
+	statsReporter := store.NewStatsReporter(serverStore, statsReportInterval, staleThreshold)
+	statsReporter.Start()
+	defer statsReporter.Stop()
+
+	prober := probe.NewProber(serverStore, apiServer.Bus)
+	prober.Start()
+	defer prober.Stop()
+	apiServer.Prober = prober
 
 	// Route multiplexing
 	mux := http.NewServeMux()
-	
+
 	// standard library handles wildcard routing nicely since 1.22
 	mux.HandleFunc("POST /api/v1/devices/{device_id}/heartbeat", apiServer.HandleHeartbeat)
 	mux.HandleFunc("POST /api/v1/devices/{device_id}/stats", apiServer.HandleStats)
 	mux.HandleFunc("GET /api/v1/devices/{device_id}/stats", apiServer.HandleStats)
+	mux.HandleFunc("GET /api/v1/devices/{device_id}/health", apiServer.HandleDeviceHealth)
+	mux.HandleFunc("GET /api/v1/health", apiServer.HandleFleetHealth)
+	mux.HandleFunc("GET /api/v1/events", apiServer.HandleEvents)
+	mux.Handle("GET /metrics", apiServer.Metrics.Handler())
+
+	handler := apiServer.Metrics.Middleware(mux)
+
+	// On SIGTERM, snapshot any buffered writes before the process exits.
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGTERM)
+	go func() {
+		<-sigCh
+		log.Println("received SIGTERM, flushing storage and shutting down...")
+		staleDetector.Stop()
+		statsReporter.Stop()
+		prober.Stop()
+		closeStore()
+		os.Exit(0)
+	}()
 
-	if err := http.ListenAndServe("127.0.0.1:6733", mux); err != nil {
+	if err := http.ListenAndServe("127.0.0.1:6733", handler); err != nil {
 		log.Fatalf("Server failed to start: %v", err)
 	}
 }
+
+// snapshotSuffix names the JSON snapshot SnapshotTo writes next to the
+// configured --storage-path when shutting down a --storage=memory
+// server, so it doesn't collide with a bolt db file at the same path.
+const snapshotSuffix = ".snapshot.json"
+
+// newBackend constructs the storage backend selected by --storage along
+// with a close func that flushes/closes it on shutdown. The in-memory
+// backend snapshots its state to a JSON file alongside path on close, so
+// a SIGTERM doesn't drop it outright.
+func newBackend(kind, path string) (store.Backend, func(), error) {
+	switch kind {
+	case "memory":
+		s := store.NewStore()
+		return s, func() {
+			if err := s.SnapshotTo(path + snapshotSuffix); err != nil {
+				log.Printf("error snapshotting in-memory storage to disk: %v", err)
+			}
+		}, nil
+	case "bolt":
+		s, err := store.NewPersistentStore(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		return s, func() {
+			if err := s.Close(); err != nil {
+				log.Printf("error closing storage backend: %v", err)
+			}
+		}, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown storage backend %q (want \"memory\" or \"bolt\")", kind)
+	}
+}
@@ -0,0 +1,115 @@
+// Package metrics exposes the fleet's Prometheus scrape endpoint.
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"fleet-metrics/store"
+)
+
+// Metrics holds the Prometheus collectors registered for the fleet server.
+type Metrics struct {
+	UploadDuration  prometheus.Histogram
+	HeartbeatsTotal *prometheus.CounterVec
+	LastHeartbeat   *prometheus.GaugeVec
+	ActiveWorkers   prometheus.Gauge
+}
+
+// New creates the fleet's collectors, registers them (along with a
+// per-device uptime collector backed directly by s) against reg, and
+// returns the Metrics handle used by the API layer to record events.
+func New(s store.Backend, reg prometheus.Registerer) *Metrics {
+	m := &Metrics{
+		UploadDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "fleet_upload_duration_seconds",
+			Help:    "Distribution of upload durations reported by devices across the fleet.",
+			Buckets: prometheus.DefBuckets,
+		}),
+		HeartbeatsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "fleet_heartbeats_total",
+			Help: "Total number of heartbeats received, per device.",
+		}, []string{"device_id"}),
+		LastHeartbeat: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "fleet_last_heartbeat_timestamp_seconds",
+			Help: "Unix timestamp of the last heartbeat received, per device.",
+		}, []string{"device_id"}),
+		ActiveWorkers: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "fleet_active_workers",
+			Help: "Number of HTTP requests currently being served.",
+		}),
+	}
+
+	reg.MustRegister(
+		m.UploadDuration,
+		m.HeartbeatsTotal,
+		m.LastHeartbeat,
+		m.ActiveWorkers,
+		newUptimeCollector(s),
+	)
+
+	return m
+}
+
+// Handler returns the http.Handler to mount at GET /metrics.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// RecordHeartbeat updates the heartbeat-related fleet metrics for deviceID.
+func (m *Metrics) RecordHeartbeat(deviceID string, sentAt time.Time) {
+	m.HeartbeatsTotal.WithLabelValues(deviceID).Inc()
+	m.LastHeartbeat.WithLabelValues(deviceID).Set(float64(sentAt.Unix()))
+}
+
+// RecordUpload updates fleet_upload_duration_seconds for an upload that
+// took uploadTime to complete.
+func (m *Metrics) RecordUpload(uploadTime time.Duration) {
+	m.UploadDuration.Observe(uploadTime.Seconds())
+}
+
+// Middleware tracks the number of HTTP requests currently in flight via
+// fleet_active_workers.
+func (m *Metrics) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.ActiveWorkers.Inc()
+		defer m.ActiveWorkers.Dec()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// uptimeCollector computes fleet_device_uptime_ratio straight from the
+// store at scrape time, reusing the same O(1) GetStats path the stats
+// endpoint uses rather than keeping a second, independently-updated gauge.
+type uptimeCollector struct {
+	store store.Backend
+	desc  *prometheus.Desc
+}
+
+func newUptimeCollector(s store.Backend) *uptimeCollector {
+	return &uptimeCollector{
+		store: s,
+		desc: prometheus.NewDesc(
+			"fleet_device_uptime_ratio",
+			"Ratio (0-100) of expected heartbeats actually received, per device.",
+			[]string{"device_id"}, nil,
+		),
+	}
+}
+
+func (c *uptimeCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *uptimeCollector) Collect(ch chan<- prometheus.Metric) {
+	c.store.Iterate(func(deviceID string) {
+		uptime, _, err := c.store.GetStats(deviceID)
+		if err != nil {
+			return
+		}
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, uptime, deviceID)
+	})
+}
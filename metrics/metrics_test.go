@@ -0,0 +1,36 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"fleet-metrics/store"
+)
+
+func TestMetrics_RecordHeartbeat(t *testing.T) {
+	s := store.NewStore()
+
+	reg := prometheus.NewRegistry()
+	m := New(s, reg)
+
+	m.RecordHeartbeat("dev-1", time.Now())
+
+	count := testutilCounterValue(t, m.HeartbeatsTotal.WithLabelValues("dev-1"))
+	if count != 1 {
+		t.Errorf("expected fleet_heartbeats_total=1, got %v", count)
+	}
+}
+
+// testutilCounterValue reads the current value of a Prometheus counter
+// without pulling in the prometheus/testutil helper package.
+func testutilCounterValue(t *testing.T, c prometheus.Counter) float64 {
+	t.Helper()
+	var metric dto.Metric
+	if err := c.Write(&metric); err != nil {
+		t.Fatalf("failed to read counter: %v", err)
+	}
+	return metric.GetCounter().GetValue()
+}
@@ -18,3 +18,20 @@ type GetDeviceStatsResponse struct {
 	AvgUploadTime string  `json:"avg_upload_time"`
 	Uptime        float64 `json:"uptime"`
 }
+
+// DeviceHealthResponse represents the response of a GET /health request
+// for a single device.
+type DeviceHealthResponse struct {
+	Status          string    `json:"status"`
+	MissedIntervals int64     `json:"missed_intervals"`
+	LastSeen        time.Time `json:"last_seen"`
+}
+
+// FleetHealthResponse represents the response of a GET /api/v1/health
+// request: a roll-up of every known device's health status.
+type FleetHealthResponse struct {
+	Healthy int                             `json:"healthy"`
+	Stale   int                             `json:"stale"`
+	Down    int                             `json:"down"`
+	Devices map[string]DeviceHealthResponse `json:"devices"`
+}
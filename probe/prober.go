@@ -0,0 +1,206 @@
+// Package probe actively tracks which devices are overdue on their next
+// expected heartbeat, rather than waiting for someone to query stats or
+// for a periodic scan to notice.
+package probe
+
+import (
+	"container/heap"
+	"log"
+	"sync"
+	"time"
+
+	"fleet-metrics/events"
+	"fleet-metrics/store"
+)
+
+const (
+	// expectedHeartbeatInterval matches the one-heartbeat-per-minute
+	// contract the rest of the fleet server assumes.
+	expectedHeartbeatInterval = time.Minute
+	// grace absorbs normal network jitter before a missed window counts
+	// against a device.
+	grace = 15 * time.Second
+	// downAfterMissed is the number of consecutive missed windows after
+	// which a device is considered down rather than merely stale.
+	downAfterMissed = 5
+)
+
+// deadline is one entry in the Prober's min-heap: the next time
+// deviceID is expected to have missed its heartbeat window. generation
+// pins it to the schedule call that created it, so a later heartbeat
+// (or reschedule) can invalidate it without having to find and remove
+// it from the heap.
+type deadline struct {
+	expectedAt time.Time
+	deviceID   string
+	generation uint64
+}
+
+type deadlineHeap []deadline
+
+func (h deadlineHeap) Len() int           { return len(h) }
+func (h deadlineHeap) Less(i, j int) bool { return h[i].expectedAt.Before(h[j].expectedAt) }
+func (h deadlineHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *deadlineHeap) Push(x any)        { *h = append(*h, x.(deadline)) }
+func (h *deadlineHeap) Pop() any {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	*h = old[:n-1]
+	return item
+}
+
+// Prober schedules an expected-next-heartbeat deadline for every device
+// and wakes a single background goroutine on the earliest one, marking
+// devices unhealthy as soon as they miss their window instead of only
+// on the next periodic scan.
+type Prober struct {
+	backend store.Backend
+	bus     *events.BufferedSubscription
+
+	mu         sync.Mutex
+	heap       deadlineHeap
+	generation map[string]uint64
+
+	wake chan struct{}
+	stop chan struct{}
+}
+
+// NewProber creates a Prober that marks devices unhealthy in backend and
+// publishes a device_stale event to bus (if non-nil) whenever one misses
+// its expected heartbeat window.
+func NewProber(backend store.Backend, bus *events.BufferedSubscription) *Prober {
+	return &Prober{
+		backend:    backend,
+		bus:        bus,
+		generation: make(map[string]uint64),
+		wake:       make(chan struct{}, 1),
+		stop:       make(chan struct{}),
+	}
+}
+
+// Start launches the background scheduling goroutine.
+func (p *Prober) Start() {
+	go p.run()
+}
+
+// Stop terminates the scheduling goroutine.
+func (p *Prober) Stop() {
+	close(p.stop)
+}
+
+// OnHeartbeat marks deviceID healthy and schedules its next expected
+// ping. Call this alongside RecordHeartbeat.
+func (p *Prober) OnHeartbeat(deviceID string, sentAt time.Time) {
+	p.backend.SetHealth(deviceID, store.HealthHealthy, 0)
+	p.schedule(deviceID, sentAt.Add(expectedHeartbeatInterval+grace))
+}
+
+// schedule pushes a new deadline for deviceID and bumps its generation,
+// which implicitly invalidates any deadline previously scheduled for it
+// still sitting in the heap (checkDue discards those on pop instead of
+// evaluating them).
+func (p *Prober) schedule(deviceID string, expectedAt time.Time) {
+	p.mu.Lock()
+	p.generation[deviceID]++
+	gen := p.generation[deviceID]
+	heap.Push(&p.heap, deadline{expectedAt: expectedAt, deviceID: deviceID, generation: gen})
+	p.mu.Unlock()
+
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (p *Prober) run() {
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+
+	for {
+		timer.Reset(p.nextWait())
+
+		select {
+		case <-timer.C:
+			p.checkDue()
+		case <-p.wake:
+			if !timer.Stop() {
+				<-timer.C
+			}
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+func (p *Prober) nextWait() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.heap.Len() == 0 {
+		return time.Hour
+	}
+	wait := time.Until(p.heap[0].expectedAt)
+	if wait < 0 {
+		return 0
+	}
+	return wait
+}
+
+// checkDue pops every deadline that has already passed and evaluates it,
+// unless it's since been superseded by a later schedule call for the
+// same device (e.g. a fresh heartbeat), in which case it's discarded.
+func (p *Prober) checkDue() {
+	now := time.Now()
+	for {
+		p.mu.Lock()
+		if p.heap.Len() == 0 || p.heap[0].expectedAt.After(now) {
+			p.mu.Unlock()
+			return
+		}
+		due := heap.Pop(&p.heap).(deadline)
+		superseded := due.generation != p.generation[due.deviceID]
+		p.mu.Unlock()
+
+		if superseded {
+			continue
+		}
+		p.evaluate(due.deviceID, now)
+	}
+}
+
+// evaluate marks deviceID healthy, stale, or down based on how many
+// consecutive heartbeat windows it has missed since its last heartbeat,
+// then reschedules it so a device that never sends another heartbeat
+// keeps being re-evaluated rather than only being flagged once.
+func (p *Prober) evaluate(deviceID string, now time.Time) {
+	lastSeen := p.backend.LastHeartbeat(deviceID)
+	if lastSeen.IsZero() {
+		return
+	}
+
+	missed := int64(now.Sub(lastSeen) / expectedHeartbeatInterval)
+
+	var status store.HealthStatus
+	switch {
+	case missed <= 0:
+		status = store.HealthHealthy
+	case missed >= downAfterMissed:
+		status = store.HealthDown
+	default:
+		status = store.HealthStale
+	}
+
+	p.backend.SetHealth(deviceID, status, missed)
+
+	if status != store.HealthHealthy {
+		log.Printf("level=warn msg=\"device missed heartbeat window\" device_id=%q status=%s missed_intervals=%d last_seen=%s",
+			deviceID, status, missed, lastSeen.Format(time.RFC3339))
+
+		if p.bus != nil {
+			p.bus.Publish(events.KindDeviceStale, deviceID)
+		}
+	}
+
+	p.schedule(deviceID, now.Add(expectedHeartbeatInterval))
+}
@@ -0,0 +1,98 @@
+package probe
+
+import (
+	"testing"
+	"time"
+
+	"fleet-metrics/events"
+	"fleet-metrics/store"
+)
+
+func TestProber_MarksDeviceStaleAfterMissedWindow(t *testing.T) {
+	s := store.NewStore()
+	bus := events.NewBufferedSubscription(10)
+	sub := bus.Subscribe(10)
+	defer sub.Close()
+
+	sentAt := time.Now().Add(-2 * time.Minute)
+	s.RecordHeartbeat("dev-1", sentAt)
+
+	p := NewProber(s, bus)
+	p.OnHeartbeat("dev-1", sentAt)
+	p.Start()
+	defer p.Stop()
+
+	select {
+	case ev := <-sub.Events():
+		if ev.Kind != events.KindDeviceStale || ev.DeviceID != "dev-1" {
+			t.Fatalf("unexpected event: %+v", ev)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected a device_stale event, got none")
+	}
+
+	status, missedIntervals, _, ok := s.Health("dev-1")
+	if !ok {
+		t.Fatalf("expected dev-1 to have a health verdict recorded")
+	}
+	if status != store.HealthStale {
+		t.Errorf("expected status %v, got %v", store.HealthStale, status)
+	}
+	if missedIntervals < 1 {
+		t.Errorf("expected at least 1 missed interval, got %d", missedIntervals)
+	}
+}
+
+// TestProber_SupersededDeadlineIsDiscardedNotEvaluated guards against the
+// steady-state bug where every OnHeartbeat call left the previous
+// cycle's deadline sitting in the heap: that stale entry would later
+// fire on its own, re-flagging an otherwise healthy, regularly
+// heartbeating device as stale and re-publishing a device_stale event
+// for it on a loop.
+func TestProber_SupersededDeadlineIsDiscardedNotEvaluated(t *testing.T) {
+	s := store.NewStore()
+	s.RecordHeartbeat("dev-1", time.Now().Add(-10*time.Minute))
+
+	bus := events.NewBufferedSubscription(10)
+	sub := bus.Subscribe(10)
+	defer sub.Close()
+
+	p := NewProber(s, bus)
+
+	// Simulate two schedule calls racing for the same device, as
+	// OnHeartbeat followed by evaluate's own reschedule would: the first
+	// (older, superseded) deadline must not also fire.
+	p.schedule("dev-1", time.Now().Add(-time.Second))
+	p.schedule("dev-1", time.Now().Add(-time.Millisecond))
+
+	p.checkDue()
+
+	select {
+	case <-sub.Events():
+	default:
+		t.Fatal("expected exactly one device_stale event from the current-generation deadline")
+	}
+	select {
+	case ev := <-sub.Events():
+		t.Fatalf("expected the superseded deadline to be discarded without evaluating, got a second event %+v", ev)
+	default:
+	}
+}
+
+func TestProber_OnHeartbeatMarksHealthy(t *testing.T) {
+	s := store.NewStore()
+	p := NewProber(s, nil)
+
+	p.OnHeartbeat("dev-1", time.Now())
+
+	status, missedIntervals, _, ok := s.Health("dev-1")
+	if !ok {
+		t.Fatalf("expected dev-1 to have a health verdict recorded")
+	}
+	if status != store.HealthHealthy {
+		t.Errorf("expected status %v, got %v", store.HealthHealthy, status)
+	}
+	if missedIntervals != 0 {
+		t.Errorf("expected 0 missed intervals, got %d", missedIntervals)
+	}
+}
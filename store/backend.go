@@ -0,0 +1,52 @@
+package store
+
+import "time"
+
+// Backend is the storage contract the API layer programs against. It lets
+// main.go swap the in-memory ServerStore for a persistent implementation
+// via --storage without the handlers caring which one is active.
+type Backend interface {
+	// RegisterDevice ensures deviceID has an entry, even before its first
+	// heartbeat (e.g. when pre-loaded from devices.csv).
+	RegisterDevice(deviceID string)
+
+	RecordHeartbeat(deviceID string, sentAt time.Time)
+	RecordUpload(deviceID string, sentAt time.Time, uploadTime int64)
+
+	// GetStats returns the uptime percentage and average upload duration
+	// for deviceID, or an error if the device is unknown or has no
+	// heartbeats recorded yet.
+	GetStats(deviceID string) (uptime float64, avgUpload time.Duration, err error)
+
+	// LastHeartbeat returns the time of the most recent heartbeat
+	// received for deviceID, or the zero time if none has been recorded.
+	LastHeartbeat(deviceID string) time.Time
+
+	// Iterate calls fn once per known device ID.
+	Iterate(fn func(deviceID string))
+
+	// LoadDevices pre-registers every device ID listed in a CSV file.
+	LoadDevices(path string) error
+
+	// Snapshot returns the current cumulative heartbeat/upload counters,
+	// for callers (e.g. StatsReporter) that need a per-interval rate.
+	Snapshot() Snapshot
+
+	// DeviceCount returns the number of known devices.
+	DeviceCount() int
+
+	// OnlineDeviceCount returns the number of devices whose last
+	// heartbeat was received within the last `within` duration.
+	OnlineDeviceCount(within time.Duration) int
+
+	// UploadP95 returns an approximate fleet-wide 95th percentile upload
+	// duration.
+	UploadP95() time.Duration
+
+	// SetHealth records a Prober's latest health verdict for deviceID.
+	SetHealth(deviceID string, status HealthStatus, missedIntervals int64)
+
+	// Health returns the last-known health verdict for deviceID. ok is
+	// false if deviceID is unknown.
+	Health(deviceID string) (status HealthStatus, missedIntervals int64, lastSeen time.Time, ok bool)
+}
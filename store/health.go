@@ -0,0 +1,45 @@
+package store
+
+import "time"
+
+// HealthStatus is the Prober's active-probing verdict for a device, as
+// opposed to the passive uptime percentage GetStats computes on demand.
+type HealthStatus int32
+
+const (
+	HealthHealthy HealthStatus = iota
+	HealthStale
+	HealthDown
+)
+
+// String returns the wire-format name used in health API responses.
+func (h HealthStatus) String() string {
+	switch h {
+	case HealthStale:
+		return "stale"
+	case HealthDown:
+		return "down"
+	default:
+		return "healthy"
+	}
+}
+
+// SetHealth records the Prober's latest verdict for deviceID.
+func (s *ServerStore) SetHealth(deviceID string, status HealthStatus, missedIntervals int64) {
+	device := s.getOrCreateDevice(deviceID)
+	device.health.Store(int32(status))
+	device.missedIntervals.Store(missedIntervals)
+}
+
+// Health returns the last-known health verdict, missed-interval count,
+// and last heartbeat time for deviceID. ok is false if deviceID is
+// unknown.
+func (s *ServerStore) Health(deviceID string) (status HealthStatus, missedIntervals int64, lastSeen time.Time, ok bool) {
+	value, found := s.DeviceMap.Load(deviceID)
+	if !found {
+		return 0, 0, time.Time{}, false
+	}
+
+	device := value.(*DeviceData)
+	return HealthStatus(device.health.Load()), device.missedIntervals.Load(), s.LastHeartbeat(deviceID), true
+}
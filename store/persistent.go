@@ -0,0 +1,208 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	flushInterval  = 100 * time.Millisecond
+	flushBatchSize = 1000
+)
+
+var eventsBucket = []byte("events")
+
+// event is the durable record written to BoltDB for each heartbeat or
+// upload. RegisterDevice isn't persisted as an event; a device shows up
+// in the db as soon as it has its first heartbeat or upload.
+type event struct {
+	Kind       string    `json:"kind"` // "heartbeat" or "upload"
+	DeviceID   string    `json:"device_id"`
+	SentAt     time.Time `json:"sent_at"`
+	UploadTime int64     `json:"upload_time,omitempty"`
+}
+
+// PersistentStore is a Backend that durably records events in BoltDB
+// while serving reads from the same in-memory indexes ServerStore uses,
+// so GetStats stays O(1) regardless of the backing store. Writes are
+// buffered and flushed in batches rather than fsync'd per request.
+type PersistentStore struct {
+	*ServerStore
+
+	db *bolt.DB
+
+	mu      sync.Mutex
+	pending []event
+
+	flush   chan struct{}
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+// NewPersistentStore opens (or creates) a BoltDB file at path, rebuilds
+// the in-memory indexes from whatever events are already on disk, and
+// starts the background batch-flush goroutine.
+func NewPersistentStore(path string) (*PersistentStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("opening bolt db: %w", err)
+	}
+
+	if err := db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(eventsBucket)
+		return err
+	}); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("initializing bolt buckets: %w", err)
+	}
+
+	ps := &PersistentStore{
+		ServerStore: NewStore(),
+		db:          db,
+		flush:       make(chan struct{}, 1),
+		done:        make(chan struct{}),
+		stopped:     make(chan struct{}),
+	}
+
+	if err := ps.rebuildIndexes(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("rebuilding indexes from bolt db: %w", err)
+	}
+
+	go ps.flushLoop()
+
+	return ps, nil
+}
+
+// rebuildIndexes replays every persisted event back through the in-memory
+// ServerStore so FirstHeartbeat/LastHeartbeat and the upload histogram
+// are warm again after a restart.
+func (ps *PersistentStore) rebuildIndexes() error {
+	return ps.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(eventsBucket).ForEach(func(_, v []byte) error {
+			var e event
+			if err := json.Unmarshal(v, &e); err != nil {
+				return fmt.Errorf("decoding persisted event: %w", err)
+			}
+			switch e.Kind {
+			case "heartbeat":
+				ps.ServerStore.RecordHeartbeat(e.DeviceID, e.SentAt)
+			case "upload":
+				ps.ServerStore.RecordUpload(e.DeviceID, e.SentAt, e.UploadTime)
+			}
+			return nil
+		})
+	})
+}
+
+// RecordHeartbeat updates the in-memory indexes immediately and queues
+// the event for the next batch write.
+func (ps *PersistentStore) RecordHeartbeat(deviceID string, sentAt time.Time) {
+	ps.ServerStore.RecordHeartbeat(deviceID, sentAt)
+	ps.enqueue(event{Kind: "heartbeat", DeviceID: deviceID, SentAt: sentAt})
+}
+
+// RecordUpload updates the in-memory indexes immediately and queues the
+// event for the next batch write.
+func (ps *PersistentStore) RecordUpload(deviceID string, sentAt time.Time, uploadTime int64) {
+	ps.ServerStore.RecordUpload(deviceID, sentAt, uploadTime)
+	ps.enqueue(event{Kind: "upload", DeviceID: deviceID, SentAt: sentAt, UploadTime: uploadTime})
+}
+
+func (ps *PersistentStore) enqueue(e event) {
+	ps.mu.Lock()
+	ps.pending = append(ps.pending, e)
+	full := len(ps.pending) >= flushBatchSize
+	ps.mu.Unlock()
+
+	if full {
+		select {
+		case ps.flush <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// flushLoop batches writes for up to flushInterval (or flushBatchSize
+// events, whichever comes first) to avoid an fsync per request.
+func (ps *PersistentStore) flushLoop() {
+	defer close(ps.stopped)
+
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			ps.flushPending()
+		case <-ps.flush:
+			ps.flushPending()
+		case <-ps.done:
+			ps.flushPending()
+			return
+		}
+	}
+}
+
+func (ps *PersistentStore) flushPending() {
+	ps.mu.Lock()
+	batch := ps.pending
+	ps.pending = nil
+	ps.mu.Unlock()
+
+	if len(batch) == 0 {
+		return
+	}
+
+	err := ps.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(eventsBucket)
+		for _, e := range batch {
+			data, err := json.Marshal(e)
+			if err != nil {
+				return fmt.Errorf("encoding event: %w", err)
+			}
+			id, err := bucket.NextSequence()
+			if err != nil {
+				return err
+			}
+			key := make([]byte, 8)
+			putUint64(key, id)
+			if err := bucket.Put(key, data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		// Events already updated the in-memory indexes, so a flush
+		// failure only risks durability, not serving correctness. Put
+		// the batch back at the front of the queue so the next flush
+		// retries it instead of silently losing it.
+		log.Printf("error flushing %d events to bolt db, will retry: %v", len(batch), err)
+
+		ps.mu.Lock()
+		ps.pending = append(batch, ps.pending...)
+		ps.mu.Unlock()
+		return
+	}
+}
+
+func putUint64(b []byte, v uint64) {
+	for i := 7; i >= 0; i-- {
+		b[i] = byte(v)
+		v >>= 8
+	}
+}
+
+// Close flushes any buffered events to disk and closes the database. It
+// is safe to call from a SIGTERM handler to snapshot state before exit.
+func (ps *PersistentStore) Close() error {
+	close(ps.done)
+	<-ps.stopped
+	return ps.db.Close()
+}
@@ -0,0 +1,75 @@
+package store
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPersistentStore_RebuildsIndexesOnRestart(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "fleet.db")
+	deviceID := "persist-device"
+	now := time.Now()
+
+	ps, err := NewPersistentStore(dbPath)
+	if err != nil {
+		t.Fatalf("failed to open persistent store: %v", err)
+	}
+
+	ps.RecordHeartbeat(deviceID, now.Add(-1*time.Minute))
+	ps.RecordHeartbeat(deviceID, now)
+	ps.RecordUpload(deviceID, now, int64(2*time.Second))
+
+	if err := ps.Close(); err != nil {
+		t.Fatalf("failed to close persistent store: %v", err)
+	}
+
+	reopened, err := NewPersistentStore(dbPath)
+	if err != nil {
+		t.Fatalf("failed to reopen persistent store: %v", err)
+	}
+	defer reopened.Close()
+
+	uptime, avgUpload, err := reopened.GetStats(deviceID)
+	if err != nil {
+		t.Fatalf("expected stats to survive restart, got error: %v", err)
+	}
+	if uptime != 100.0 {
+		t.Errorf("expected 100.0 uptime after restart, got %v", uptime)
+	}
+	if avgUpload != 2*time.Second {
+		t.Errorf("expected 2s avg upload after restart, got %v", avgUpload)
+	}
+}
+
+func TestPersistentStore_RequeuesBatchOnFlushFailure(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "fleet.db")
+	deviceID := "persist-device"
+	now := time.Now()
+
+	ps, err := NewPersistentStore(dbPath)
+	if err != nil {
+		t.Fatalf("failed to open persistent store: %v", err)
+	}
+	defer ps.Close()
+
+	ps.enqueue(event{Kind: "heartbeat", DeviceID: deviceID, SentAt: now})
+
+	// Close the underlying db out from under the background flushLoop so
+	// its next flush attempt fails.
+	if err := ps.db.Close(); err != nil {
+		t.Fatalf("failed to close db: %v", err)
+	}
+
+	// Give the background loop a couple of ticks to attempt (and fail) a
+	// flush against the now-closed db.
+	time.Sleep(3 * flushInterval)
+
+	ps.mu.Lock()
+	pending := len(ps.pending)
+	ps.mu.Unlock()
+
+	if pending == 0 {
+		t.Fatal("expected the failed batch to be requeued onto pending, got none")
+	}
+}
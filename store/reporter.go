@@ -0,0 +1,84 @@
+package store
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/dustin/go-humanize"
+)
+
+// StatsReporter periodically prints a human-readable fleet-wide summary
+// line. Heartbeat/upload rates are computed from the delta between
+// consecutive Snapshot() calls rather than lifetime totals, so the
+// numbers reflect recent throughput instead of averaging over the
+// server's entire uptime.
+type StatsReporter struct {
+	backend      Backend
+	interval     time.Duration
+	onlineWithin time.Duration
+
+	start time.Time
+	stop  chan struct{}
+}
+
+// NewStatsReporter creates a reporter that logs every interval, counting
+// a device as online if its last heartbeat was within onlineWithin.
+func NewStatsReporter(backend Backend, interval, onlineWithin time.Duration) *StatsReporter {
+	return &StatsReporter{
+		backend:      backend,
+		interval:     interval,
+		onlineWithin: onlineWithin,
+		stop:         make(chan struct{}),
+	}
+}
+
+// Start launches the background reporting loop.
+func (r *StatsReporter) Start() {
+	r.start = time.Now()
+	go r.run()
+}
+
+// Stop terminates the reporting loop.
+func (r *StatsReporter) Stop() {
+	close(r.stop)
+}
+
+func (r *StatsReporter) run() {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	prev := r.backend.Snapshot()
+	for {
+		select {
+		case <-ticker.C:
+			cur := r.backend.Snapshot()
+			r.log(prev, cur)
+			prev = cur
+		case <-r.stop:
+			return
+		}
+	}
+}
+
+func (r *StatsReporter) log(prev, cur Snapshot) {
+	seconds := r.interval.Seconds()
+	hbRate := float64(cur.TotalHeartbeats-prev.TotalHeartbeats) / seconds
+	upRate := float64(cur.TotalUploads-prev.TotalUploads) / seconds
+
+	totalDevices := r.backend.DeviceCount()
+	online := r.backend.OnlineDeviceCount(r.onlineWithin)
+	onlinePct := 100.0
+	if totalDevices > 0 {
+		onlinePct = float64(online) / float64(totalDevices) * 100
+	}
+
+	fmt.Printf(
+		"elapsed=%s total_devices=%s hb=%.0f/s up=%.0f/s p95_upload=%s online=%.0f%%\n",
+		time.Since(r.start).Round(time.Second),
+		humanize.SI(float64(totalDevices), ""),
+		hbRate,
+		upRate,
+		r.backend.UploadP95().Round(time.Millisecond),
+		onlinePct,
+	)
+}
@@ -0,0 +1,82 @@
+package store
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatsReporter_LogComputesDeltaRates(t *testing.T) {
+	s := NewStore()
+	r := NewStatsReporter(s, 2*time.Second, time.Minute)
+	r.start = time.Now()
+
+	prev := Snapshot{TotalHeartbeats: 10, TotalUploads: 4}
+	cur := Snapshot{TotalHeartbeats: 30, TotalUploads: 8}
+
+	out := captureStdout(t, func() {
+		r.log(prev, cur)
+	})
+
+	// 20 heartbeats and 4 uploads over the reporter's 2s interval.
+	if !strings.Contains(out, "hb=10/s") {
+		t.Errorf("expected hb=10/s, got %q", out)
+	}
+	if !strings.Contains(out, "up=2/s") {
+		t.Errorf("expected up=2/s, got %q", out)
+	}
+}
+
+func TestStatsReporter_LogReportsOnlinePercentage(t *testing.T) {
+	s := NewStore()
+	s.RecordHeartbeat("dev-online", time.Now())
+	s.RecordHeartbeat("dev-offline", time.Now().Add(-time.Hour))
+
+	r := NewStatsReporter(s, time.Second, time.Minute)
+	r.start = time.Now()
+
+	out := captureStdout(t, func() {
+		r.log(Snapshot{}, Snapshot{})
+	})
+
+	if !strings.Contains(out, "total_devices=2") {
+		t.Errorf("expected total_devices=2, got %q", out)
+	}
+	if !strings.Contains(out, "online=50%") {
+		t.Errorf("expected online=50%% (1 of 2 devices within the window), got %q", out)
+	}
+}
+
+func TestStatsReporter_StartAndStop(t *testing.T) {
+	s := NewStore()
+	r := NewStatsReporter(s, time.Millisecond, time.Minute)
+	r.Start()
+	time.Sleep(10 * time.Millisecond)
+	r.Stop()
+}
+
+// captureStdout redirects os.Stdout for the duration of fn and returns
+// whatever it wrote.
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+
+	old := os.Stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("failed to create pipe: %v", err)
+	}
+	os.Stdout = w
+
+	fn()
+
+	w.Close()
+	os.Stdout = old
+
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(r); err != nil {
+		t.Fatalf("failed to read captured output: %v", err)
+	}
+	return buf.String()
+}
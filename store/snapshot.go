@@ -0,0 +1,66 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// DeviceSnapshot is the durable summary of a single device's in-memory
+// state, as written by SnapshotTo. It captures enough to see what a
+// device was doing at shutdown; it is not itself a Backend format, so
+// restoring from it into a running server is a manual/operational step.
+type DeviceSnapshot struct {
+	DeviceID         string    `json:"device_id"`
+	HeartbeatCount   int64     `json:"heartbeat_count"`
+	FirstHeartbeat   time.Time `json:"first_heartbeat"`
+	LastHeartbeat    time.Time `json:"last_heartbeat"`
+	UploadSumSeconds float64   `json:"upload_sum_seconds"`
+	UploadCount      uint64    `json:"upload_count"`
+}
+
+// SnapshotTo writes a JSON summary of every known device's in-memory
+// state to path. It exists so a server started with --storage=memory
+// doesn't lose its data outright on SIGTERM: main.go calls this from the
+// memory backend's close func, giving an operator a snapshot to inspect
+// or replay instead of nothing at all.
+func (s *ServerStore) SnapshotTo(path string) error {
+	var snapshots []DeviceSnapshot
+	s.Iterate(func(deviceID string) {
+		value, ok := s.DeviceMap.Load(deviceID)
+		if !ok {
+			return
+		}
+
+		device := value.(*DeviceData)
+		sumSeconds, count := readHistogram(device.UploadDuration)
+
+		snapshots = append(snapshots, DeviceSnapshot{
+			DeviceID:         deviceID,
+			HeartbeatCount:   device.heartbeatCount.Load(),
+			FirstHeartbeat:   nanoToTime(device.firstHeartbeatNano.Load()),
+			LastHeartbeat:    nanoToTime(device.lastHeartbeatNano.Load()),
+			UploadSumSeconds: sumSeconds,
+			UploadCount:      count,
+		})
+	})
+
+	data, err := json.MarshalIndent(snapshots, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding snapshot: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("writing snapshot file: %w", err)
+	}
+	return nil
+}
+
+// nanoToTime converts a UnixNano timestamp back to a time.Time, treating
+// 0 ("not yet set") as the zero time rather than the Unix epoch.
+func nanoToTime(nano int64) time.Time {
+	if nano == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nano)
+}
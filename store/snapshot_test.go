@@ -0,0 +1,48 @@
+package store
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestServerStore_SnapshotTo(t *testing.T) {
+	s := NewStore()
+	deviceID := "dev-1"
+	now := time.Now()
+
+	s.RecordHeartbeat(deviceID, now.Add(-1*time.Minute))
+	s.RecordHeartbeat(deviceID, now)
+	s.RecordUpload(deviceID, now, int64(2*time.Second))
+
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+	if err := s.SnapshotTo(path); err != nil {
+		t.Fatalf("SnapshotTo failed: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read snapshot file: %v", err)
+	}
+
+	var snapshots []DeviceSnapshot
+	if err := json.Unmarshal(data, &snapshots); err != nil {
+		t.Fatalf("failed to decode snapshot: %v", err)
+	}
+
+	if len(snapshots) != 1 {
+		t.Fatalf("expected 1 device in snapshot, got %d", len(snapshots))
+	}
+	got := snapshots[0]
+	if got.DeviceID != deviceID {
+		t.Errorf("expected device ID %q, got %q", deviceID, got.DeviceID)
+	}
+	if got.HeartbeatCount != 2 {
+		t.Errorf("expected heartbeat count 2, got %d", got.HeartbeatCount)
+	}
+	if got.UploadCount != 1 {
+		t.Errorf("expected upload count 1, got %d", got.UploadCount)
+	}
+}
@@ -0,0 +1,87 @@
+package store
+
+import (
+	"sync"
+	"time"
+
+	"fleet-metrics/events"
+)
+
+// StaleDetector periodically scans a Backend for devices whose last
+// heartbeat is older than threshold and publishes a device_stale event
+// on the healthy->stale transition, so operators learn about a fleet
+// outage from the event stream instead of having to poll every device's
+// stats, without the stream being flooded by one event per device per
+// scan for as long as the outage lasts.
+type StaleDetector struct {
+	backend   Backend
+	bus       *events.BufferedSubscription
+	interval  time.Duration
+	threshold time.Duration
+	stop      chan struct{}
+
+	mu    sync.Mutex
+	stale map[string]bool
+}
+
+// NewStaleDetector creates a detector that scans backend every interval
+// and flags devices whose last heartbeat is older than threshold.
+func NewStaleDetector(backend Backend, bus *events.BufferedSubscription, interval, threshold time.Duration) *StaleDetector {
+	return &StaleDetector{
+		backend:   backend,
+		bus:       bus,
+		interval:  interval,
+		threshold: threshold,
+		stop:      make(chan struct{}),
+		stale:     make(map[string]bool),
+	}
+}
+
+// Start launches the background scan loop.
+func (d *StaleDetector) Start() {
+	go d.run()
+}
+
+// Stop terminates the scan loop.
+func (d *StaleDetector) Stop() {
+	close(d.stop)
+}
+
+func (d *StaleDetector) run() {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			d.scan()
+		case <-d.stop:
+			return
+		}
+	}
+}
+
+func (d *StaleDetector) scan() {
+	now := time.Now()
+	d.backend.Iterate(func(deviceID string) {
+		last := d.backend.LastHeartbeat(deviceID)
+		if last.IsZero() {
+			return
+		}
+
+		isStale := now.Sub(last) > d.threshold
+
+		d.mu.Lock()
+		wasStale := d.stale[deviceID]
+		if isStale {
+			d.stale[deviceID] = true
+		} else {
+			delete(d.stale, deviceID)
+		}
+		d.mu.Unlock()
+
+		if isStale && !wasStale {
+			d.bus.Publish(events.KindDeviceStale, deviceID)
+		}
+	})
+}
@@ -0,0 +1,56 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"fleet-metrics/events"
+)
+
+func TestStaleDetector_PublishesStaleEvent(t *testing.T) {
+	s := NewStore()
+	s.RecordHeartbeat("dev-1", time.Now().Add(-10*time.Minute))
+
+	bus := events.NewBufferedSubscription(10)
+	sub := bus.Subscribe(10)
+	defer sub.Close()
+
+	detector := NewStaleDetector(s, bus, time.Millisecond, 2*time.Minute)
+	detector.Start()
+	defer detector.Stop()
+
+	select {
+	case ev := <-sub.Events():
+		if ev.Kind != events.KindDeviceStale || ev.DeviceID != "dev-1" {
+			t.Fatalf("unexpected event: %+v", ev)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected a device_stale event, got none")
+	}
+}
+
+func TestStaleDetector_DoesNotRepublishWhileStillStale(t *testing.T) {
+	s := NewStore()
+	s.RecordHeartbeat("dev-1", time.Now().Add(-10*time.Minute))
+
+	bus := events.NewBufferedSubscription(10)
+	sub := bus.Subscribe(10)
+	defer sub.Close()
+
+	detector := NewStaleDetector(s, bus, time.Millisecond, 2*time.Minute)
+
+	detector.scan()
+	select {
+	case <-sub.Events():
+	default:
+		t.Fatal("expected the first scan to publish a device_stale event")
+	}
+
+	detector.scan()
+	detector.scan()
+	select {
+	case ev := <-sub.Events():
+		t.Fatalf("expected no further events while dev-1 stays stale, got %+v", ev)
+	default:
+	}
+}
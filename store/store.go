@@ -1,23 +1,77 @@
 package store
 
 import (
+	"encoding/csv"
 	"fmt"
+	"os"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 )
 
-// DeviceData holds the metrics for a single device
+// heartbeatRingMinutes bounds how many distinct minutes of heartbeat
+// presence we remember per device (24h at one heartbeat/minute). Bits
+// are never cleared: once the ring wraps, a heartbeat whose slot is
+// already set is not counted again, so heartbeatCount plateaus at
+// heartbeatRingMinutes and GetStats caps its window to the same length.
+// A device that's been up for more than heartbeatRingMinutes would need
+// a longer ring to stay perfectly accurate, which is outside this fleet's
+// one-heartbeat-per-minute, restart-often operating assumptions.
+const heartbeatRingMinutes = 1440
+
+// heartbeatRingWords is the number of uint64 words needed to back
+// heartbeatRingMinutes bits, rounded up: a plain "/ 64" truncates and
+// leaves the ring's last partial word out of the backing array, so any
+// idx in that final word's range indexes out of bounds.
+const heartbeatRingWords = (heartbeatRingMinutes + 63) / 64
+
+// DeviceData holds the metrics for a single device. Heartbeat presence
+// and first/last timestamps are tracked with atomics so concurrent
+// heartbeats for the same device don't serialize behind a mutex; upload
+// durations go straight into a Prometheus histogram, which already
+// synchronizes its own updates internally.
 type DeviceData struct {
-	mu            sync.RWMutex
-	Heartbeats    map[time.Time]bool
-	UploadTimes   []int64
-	FirstHeartbeat time.Time
-	LastHeartbeat  time.Time
+	// heartbeatBitmap is a ring buffer of one presence bit per minute,
+	// indexed by (minutesSinceEpoch % heartbeatRingMinutes). Bits are set
+	// with a CAS loop rather than a lock.
+	heartbeatBitmap [heartbeatRingWords]uint64
+	heartbeatCount  atomic.Int64
+
+	// firstHeartbeatNano/lastHeartbeatNano store UnixNano timestamps; 0
+	// means "not yet set".
+	firstHeartbeatNano atomic.Int64
+	lastHeartbeatNano  atomic.Int64
+
+	// UploadDuration accumulates upload durations in a Prometheus
+	// histogram so GetStats can read the sum/count in O(1) instead of
+	// re-averaging a growing []int64 on every request.
+	UploadDuration prometheus.Histogram
+
+	// health/missedIntervals hold the Prober's latest verdict; see
+	// HealthStatus in health.go.
+	health          atomic.Int32
+	missedIntervals atomic.Int64
 }
 
 // ServerStore is the main thread-safe data store
 type ServerStore struct {
 	DeviceMap sync.Map // map[string]*DeviceData
+
+	// totalHeartbeats/totalUploads are fleet-wide cumulative counters,
+	// independent of any per-device state, so StatsReporter can diff
+	// consecutive snapshots into a per-interval rate without scanning
+	// every device.
+	totalHeartbeats atomic.Uint64
+	totalUploads    atomic.Uint64
+}
+
+// Snapshot is a point-in-time read of ServerStore's cumulative counters.
+type Snapshot struct {
+	TotalHeartbeats uint64
+	TotalUploads    uint64
 }
 
 // NewStore creates a new in-memory store
@@ -25,38 +79,232 @@ func NewStore() *ServerStore {
 	return &ServerStore{}
 }
 
+// RegisterDevice ensures deviceID has a (possibly empty) entry in the
+// store, so it is counted by Iterate and DeviceCount before its first
+// heartbeat. GetStats still returns an error for it until a heartbeat
+// arrives.
+func (s *ServerStore) RegisterDevice(deviceID string) {
+	s.getOrCreateDevice(deviceID)
+}
+
+// Iterate calls fn once per known device ID.
+func (s *ServerStore) Iterate(fn func(deviceID string)) {
+	s.DeviceMap.Range(func(key, _ any) bool {
+		if deviceID, ok := key.(string); ok {
+			fn(deviceID)
+		}
+		return true
+	})
+}
+
+// LoadDevices pre-registers every device ID listed in the CSV file at
+// path (one device ID per record), so known devices are iterated and
+// counted even before they've sent a heartbeat. Their stats and uptime
+// metric stay absent until the first heartbeat.
+func (s *ServerStore) LoadDevices(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("opening devices file: %w", err)
+	}
+	defer f.Close()
+
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return fmt.Errorf("reading devices file: %w", err)
+	}
+
+	for _, record := range records {
+		if len(record) == 0 || record[0] == "" {
+			continue
+		}
+		s.RegisterDevice(record[0])
+	}
+	return nil
+}
+
 // getOrCreateDevice retrieves or creates the data store for a given device ID
 func (s *ServerStore) getOrCreateDevice(deviceID string) *DeviceData {
-	data, _ := s.DeviceMap.LoadOrStore(deviceID, &DeviceData{})
+	data, _ := s.DeviceMap.LoadOrStore(deviceID, &DeviceData{
+		UploadDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "device_upload_duration_seconds",
+			Help:    "Per-device upload duration distribution, used internally to compute GetStats averages.",
+			Buckets: prometheus.DefBuckets,
+		}),
+	})
 	return data.(*DeviceData)
 }
 
 // RecordHeartbeat adds a heartbeat to a device's record
 func (s *ServerStore) RecordHeartbeat(deviceID string, sentAt time.Time) {
 	device := s.getOrCreateDevice(deviceID)
-	device.mu.Lock()
-	defer device.mu.Unlock()
 
-	if device.Heartbeats == nil {
-		device.Heartbeats = make(map[time.Time]bool)
+	minute := sentAt.Unix() / 60
+	if device.markHeartbeatMinute(minute) {
+		device.heartbeatCount.Add(1)
 	}
-	device.Heartbeats[sentAt] = true
 
-	if device.FirstHeartbeat.IsZero() || sentAt.Before(device.FirstHeartbeat) {
-		device.FirstHeartbeat = sentAt
-	}
-	if device.LastHeartbeat.IsZero() || sentAt.After(device.LastHeartbeat) {
-		device.LastHeartbeat = sentAt
-	}
+	casMin(&device.firstHeartbeatNano, sentAt.UnixNano())
+	casMax(&device.lastHeartbeatNano, sentAt.UnixNano())
+
+	s.totalHeartbeats.Add(1)
 }
 
 // RecordUpload adds an upload duration to a device's record
 func (s *ServerStore) RecordUpload(deviceID string, sentAt time.Time, uploadTime int64) {
 	device := s.getOrCreateDevice(deviceID)
-	device.mu.Lock()
-	defer device.mu.Unlock()
+	device.UploadDuration.Observe(time.Duration(uploadTime).Seconds())
+
+	s.totalUploads.Add(1)
+}
+
+// markHeartbeatMinute sets the presence bit for minute in the ring
+// buffer and reports whether it was newly set (as opposed to already
+// having a heartbeat recorded for that minute).
+func (d *DeviceData) markHeartbeatMinute(minute int64) (isNew bool) {
+	idx := minute % heartbeatRingMinutes
+	if idx < 0 {
+		idx += heartbeatRingMinutes
+	}
+	word := &d.heartbeatBitmap[idx/64]
+	bit := uint64(1) << uint(idx%64)
+
+	for {
+		old := atomic.LoadUint64(word)
+		if old&bit != 0 {
+			return false
+		}
+		if atomic.CompareAndSwapUint64(word, old, old|bit) {
+			return true
+		}
+	}
+}
+
+// casMin atomically sets a to v if a is unset (0) or v is earlier.
+func casMin(a *atomic.Int64, v int64) {
+	for {
+		old := a.Load()
+		if old != 0 && old <= v {
+			return
+		}
+		if a.CompareAndSwap(old, v) {
+			return
+		}
+	}
+}
 
-	device.UploadTimes = append(device.UploadTimes, uploadTime)
+// casMax atomically sets a to v if v is later than the current value.
+func casMax(a *atomic.Int64, v int64) {
+	for {
+		old := a.Load()
+		if old >= v {
+			return
+		}
+		if a.CompareAndSwap(old, v) {
+			return
+		}
+	}
+}
+
+// readHistogram extracts the running sum and count from a Prometheus
+// histogram without iterating the underlying samples.
+func readHistogram(h prometheus.Histogram) (sum float64, count uint64) {
+	var metric dto.Metric
+	if err := h.Write(&metric); err != nil {
+		return 0, 0
+	}
+	return metric.GetHistogram().GetSampleSum(), metric.GetHistogram().GetSampleCount()
+}
+
+// Snapshot returns the current cumulative heartbeat/upload counters.
+func (s *ServerStore) Snapshot() Snapshot {
+	return Snapshot{
+		TotalHeartbeats: s.totalHeartbeats.Load(),
+		TotalUploads:    s.totalUploads.Load(),
+	}
+}
+
+// DeviceCount returns the number of known devices.
+func (s *ServerStore) DeviceCount() int {
+	count := 0
+	s.Iterate(func(string) { count++ })
+	return count
+}
+
+// OnlineDeviceCount returns the number of devices whose last heartbeat
+// was received within the last `within` duration.
+func (s *ServerStore) OnlineDeviceCount(within time.Duration) int {
+	now := time.Now()
+	count := 0
+	s.Iterate(func(deviceID string) {
+		last := s.LastHeartbeat(deviceID)
+		if !last.IsZero() && now.Sub(last) <= within {
+			count++
+		}
+	})
+	return count
+}
+
+// UploadP95 returns an approximate fleet-wide 95th percentile upload
+// duration, derived by merging the bucket counts of every device's
+// upload histogram (they all share the same bucket boundaries) and
+// reporting the upper bound of the bucket the 95th percentile falls in.
+// It returns 0 when no uploads have been recorded, and also when the 95th
+// percentile lies above the largest finite bucket bound (10s with
+// prometheus.DefBuckets).
+func (s *ServerStore) UploadP95() time.Duration {
+	var upperBounds []float64
+	var cumulative []uint64
+	var totalCount uint64
+
+	s.Iterate(func(deviceID string) {
+		value, ok := s.DeviceMap.Load(deviceID)
+		if !ok {
+			return
+		}
+		var metric dto.Metric
+		if err := value.(*DeviceData).UploadDuration.Write(&metric); err != nil {
+			return
+		}
+		h := metric.GetHistogram()
+		if upperBounds == nil {
+			upperBounds = make([]float64, len(h.GetBucket()))
+			cumulative = make([]uint64, len(h.GetBucket()))
+			for i, b := range h.GetBucket() {
+				upperBounds[i] = b.GetUpperBound()
+			}
+		}
+		for i, b := range h.GetBucket() {
+			cumulative[i] += b.GetCumulativeCount()
+		}
+		totalCount += h.GetSampleCount()
+	})
+
+	if totalCount == 0 {
+		return 0
+	}
+
+	target := uint64((0.95 * float64(totalCount)) + 0.5)
+	for i, count := range cumulative {
+		if count >= target {
+			return time.Duration(upperBounds[i] * float64(time.Second))
+		}
+	}
+	return 0
+}
+
+// LastHeartbeat returns the time of the most recent heartbeat received
+// for deviceID, or the zero time if none has been recorded.
+func (s *ServerStore) LastHeartbeat(deviceID string) time.Time {
+	value, ok := s.DeviceMap.Load(deviceID)
+	if !ok {
+		return time.Time{}
+	}
+
+	nano := value.(*DeviceData).lastHeartbeatNano.Load()
+	if nano == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nano)
 }
 
 // GetStats calculates and returns the uptime and average upload time
@@ -68,26 +316,38 @@ func (s *ServerStore) GetStats(deviceID string) (float64, time.Duration, error)
 	}
 
 	device := value.(*DeviceData)
-	device.mu.RLock()
-	defer device.mu.RUnlock()
 
-	if len(device.Heartbeats) == 0 {
+	heartbeatCount := device.heartbeatCount.Load()
+	if heartbeatCount == 0 {
 		return 0, 0, fmt.Errorf("no heartbeats found for device")
 	}
 
+	firstHeartbeat := time.Unix(0, device.firstHeartbeatNano.Load())
+	lastHeartbeat := time.Unix(0, device.lastHeartbeatNano.Load())
+
 	// 1. Calculate Uptime
 	uptime := 100.0 // Default to 100% if only one heartbeat
-	minutesDiff := device.LastHeartbeat.Sub(device.FirstHeartbeat).Minutes()
+	minutesDiff := lastHeartbeat.Sub(firstHeartbeat).Minutes()
 
 	if minutesDiff > 0 {
 		// Calculate the number of full minutes
-		numMinutes := float64(int(minutesDiff)) 
-		
+		numMinutes := float64(int(minutesDiff))
+
 		// If exactly on a minute boundary or less than a minute, avoid zero division
 		if numMinutes > 0 {
+			// heartbeatCount can never exceed heartbeatRingMinutes (the ring
+			// has no more distinct minute-of-day slots to set), so once a
+			// device has been up longer than that, numMinutes must be capped
+			// to the same window. Otherwise a perfectly healthy, long-lived
+			// device's uptime keeps decaying toward 0 as minutesDiff grows
+			// while heartbeatCount has long since plateaued.
+			if numMinutes > heartbeatRingMinutes {
+				numMinutes = heartbeatRingMinutes
+			}
+
 			// Each device sends a heartbeat every minute. We expected numMinutes + 1 heartbeats.
 			// But the formula given is: uptime = (sumHeartbeats / numMinutesBetweenFirstAndLastHeartbeat) * 100
-			uptime = (float64(len(device.Heartbeats)) / numMinutes) * 100
+			uptime = (float64(heartbeatCount) / numMinutes) * 100
 			// Cap at 100% just in case of duplicate heartbeats in the same minute
 			if uptime > 100 {
 				uptime = 100
@@ -97,14 +357,11 @@ func (s *ServerStore) GetStats(deviceID string) (float64, time.Duration, error)
 
 	// 2. Calculate Average Upload Time
 	var avgUpload time.Duration
-	if len(device.UploadTimes) > 0 {
-		var sum int64
-		for _, t := range device.UploadTimes {
-			sum += t
-		}
-		// Calculate average in nanoseconds, then convert to time.Duration
-		avgNanoseconds := sum / int64(len(device.UploadTimes))
-		avgUpload = time.Duration(avgNanoseconds)
+	if device.UploadDuration != nil {
+		sumSeconds, count := readHistogram(device.UploadDuration)
+		if count > 0 {
+			avgUpload = time.Duration(sumSeconds / float64(count) * float64(time.Second))
+		}
 	}
 
 	return uptime, avgUpload, nil
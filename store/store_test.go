@@ -92,6 +92,53 @@ func TestStore_UploadStats(t *testing.T) {
 	}
 }
 
+func TestStore_Heartbeats_FullRingRange(t *testing.T) {
+	s := NewStore()
+	deviceID := "test-device"
+	s.RegisterDevice(deviceID)
+
+	// Exercise every minute-of-ring index, including the ones in the
+	// ring's final, previously out-of-bounds word (idx >= 1408).
+	base := time.Unix(0, 0)
+	for minute := int64(0); minute < heartbeatRingMinutes; minute++ {
+		s.RecordHeartbeat(deviceID, base.Add(time.Duration(minute)*time.Minute))
+	}
+
+	uptime, _, err := s.GetStats(deviceID)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if uptime != 100.0 {
+		t.Errorf("expected 100.0 uptime after a full ring of heartbeats, got %v", uptime)
+	}
+}
+
+func TestStore_Heartbeats_LongRunningDeviceUptimeStaysHigh(t *testing.T) {
+	s := NewStore()
+	deviceID := "test-device"
+	s.RegisterDevice(deviceID)
+
+	start := time.Unix(0, 0)
+	for minute := int64(0); minute < heartbeatRingMinutes; minute++ {
+		s.RecordHeartbeat(deviceID, start.Add(time.Duration(minute)*time.Minute))
+	}
+
+	// 30 days later, still heartbeating on the same ring positions: the
+	// ring's heartbeat count has long since plateaued at its max, but a
+	// naive lastHeartbeat-firstHeartbeat window would make uptime decay
+	// toward 0 as the real elapsed time keeps growing.
+	last := start.Add(30 * 24 * time.Hour)
+	s.RecordHeartbeat(deviceID, last)
+
+	uptime, _, err := s.GetStats(deviceID)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if uptime < 99.0 {
+		t.Errorf("expected a healthy long-running device to report a high uptime, got %v", uptime)
+	}
+}
+
 func TestStore_Concurrency(t *testing.T) {
 	s := NewStore()
 	deviceID := "test-device"
@@ -123,6 +170,38 @@ func TestStore_Concurrency(t *testing.T) {
 	fmt.Println("Concurrency test passed")
 }
 
+func TestStore_SnapshotAndFleetAggregates(t *testing.T) {
+	s := NewStore()
+	now := time.Now()
+
+	s.RegisterDevice("dev-1")
+	s.RegisterDevice("dev-2")
+
+	s.RecordHeartbeat("dev-1", now)
+	s.RecordHeartbeat("dev-2", now.Add(-10*time.Minute))
+	s.RecordUpload("dev-1", now, int64(time.Second))
+
+	snap := s.Snapshot()
+	if snap.TotalHeartbeats != 2 {
+		t.Errorf("expected 2 cumulative heartbeats, got %d", snap.TotalHeartbeats)
+	}
+	if snap.TotalUploads != 1 {
+		t.Errorf("expected 1 cumulative upload, got %d", snap.TotalUploads)
+	}
+
+	if got := s.DeviceCount(); got != 2 {
+		t.Errorf("expected 2 devices, got %d", got)
+	}
+
+	if got := s.OnlineDeviceCount(2 * time.Minute); got != 1 {
+		t.Errorf("expected 1 online device, got %d", got)
+	}
+
+	if got := s.UploadP95(); got <= 0 {
+		t.Errorf("expected a positive p95 upload duration, got %v", got)
+	}
+}
+
 func BenchmarkGetStats(b *testing.B) {
 	s := NewStore()
 	deviceID := "test-device"